@@ -0,0 +1,52 @@
+package knx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vapourismo/knx-go/knx/proto"
+)
+
+// newBusmonTestTunnel builds a Tunnel configured for the busmon layer without dialing a real
+// gateway, so that the read-only guard can be exercised without a Socket.
+func newBusmonTestTunnel() *Tunnel {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Tunnel{
+		tunnelConn: tunnelConn{
+			config:    checkTunnelConfig(TunnelConfig{Layer: proto.TunnelLayerBusmon}),
+			shutdownC: make(chan struct{}),
+			serveDone: make(chan struct{}),
+		},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func TestSendContextRejectsOnBusmonTunnel(t *testing.T) {
+	client := newBusmonTestTunnel()
+
+	if err := client.SendContext(context.Background(), nil); err != ErrBusmonReadOnly {
+		t.Fatalf("SendContext() = %v, want ErrBusmonReadOnly", err)
+	}
+}
+
+func TestSendRejectsOnBusmonTunnel(t *testing.T) {
+	client := newBusmonTestTunnel()
+
+	if err := client.Send(nil); err != ErrBusmonReadOnly {
+		t.Fatalf("Send() = %v, want ErrBusmonReadOnly", err)
+	}
+}
+
+func TestBusmonGuardTakesPrecedenceOverShutdown(t *testing.T) {
+	client := newBusmonTestTunnel()
+	client.beginShutdown()
+
+	// The busmon guard is checked before the shutdown guard, so a busmon tunnel reports that it
+	// is read-only regardless of shutdown state.
+	err := client.Send(nil)
+	if err != ErrBusmonReadOnly {
+		t.Fatalf("Send() = %v, want ErrBusmonReadOnly", err)
+	}
+}
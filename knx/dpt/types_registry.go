@@ -4,98 +4,157 @@
 package dpt
 
 import (
+	"fmt"
 	"reflect"
+	"regexp"
+	"sort"
 	"sync"
 )
 
+var types = [...]DatapointValue{
+	// 1.xxx
+	new(DPT_1001),
+	new(DPT_1002),
+	new(DPT_1003),
+	new(DPT_1009),
+	new(DPT_1010),
+
+	// 5.xxx
+	new(DPT_5001),
+	new(DPT_5003),
+	new(DPT_5004),
+
+	// 9.xxx
+	new(DPT_9001),
+	new(DPT_9004),
+	new(DPT_9005),
+	new(DPT_9007),
+
+	// 12.xxx
+	new(DPT_12001),
+
+	// 13.xxx
+	new(DPT_13001),
+	new(DPT_13002),
+	new(DPT_13010),
+	new(DPT_13011),
+	new(DPT_13012),
+	new(DPT_13013),
+	new(DPT_13014),
+	new(DPT_13015),
+	// 17.xxx
+	new(DPT_17001),
+	// 18.xxx
+	new(DPT_18001),
+}
+
+// registryNamePattern matches the KNX "mm.nnn" datapoint-type name format, e.g. "1.001" or
+// "232.600".
+var registryNamePattern = regexp.MustCompile(`^[0-9]+\.[0-9]{3}$`)
+
 var (
-	types = [...]DatapointValue{
-		// 1.xxx
-		new(DPT_1001),
-		new(DPT_1002),
-		new(DPT_1003),
-		new(DPT_1009),
-		new(DPT_1010),
-
-		// 5.xxx
-		new(DPT_5001),
-		new(DPT_5003),
-		new(DPT_5004),
-
-		// 9.xxx
-		new(DPT_9001),
-		new(DPT_9004),
-		new(DPT_9005),
-		new(DPT_9007),
-
-		// 12.xxx
-		new(DPT_12001),
-
-		// 13.xxx
-		new(DPT_13001),
-		new(DPT_13002),
-		new(DPT_13010),
-		new(DPT_13011),
-		new(DPT_13012),
-		new(DPT_13013),
-		new(DPT_13014),
-		new(DPT_13015),
-		// 17.xxx
-		new(DPT_17001),
-		// 18.xxx
-		new(DPT_18001),
-	}
-	once     sync.Once
-	registry map[string]reflect.Type
+	registryMu sync.RWMutex
+	registry   map[string]reflect.Type
 )
 
-// Init function used to add all types
-func setup() {
-	// Singleton, can only run once
-	once.Do(func() {
-		// Register the types
-		registry = make(map[string]reflect.Type)
-		for _, d := range types {
-			// Determine the name of the datatype
-			d_type := reflect.TypeOf(d).Elem()
-			name := d_type.Name()
-
-			// Convert the name into KNX yy.xxx (e.g. DPT_1001 --> 1.001) format
-			name = name[4:len(name)-3] + "." + name[len(name)-3:]
-
-			// Register the type
-			registry[name] = d_type
-		}
-	})
+// init seeds the registry with the built-in types. Unlike the old lazy, sync.Once-guarded setup,
+// this runs eagerly so that user code can call Register at any time, including from its own
+// init functions, without racing the first Produce/ListSupportedTypes call.
+func init() {
+	registry = make(map[string]reflect.Type, len(types))
+
+	for _, d := range types {
+		// Determine the name of the datatype.
+		d_type := reflect.TypeOf(d).Elem()
+		name := d_type.Name()
+
+		// Convert the name into KNX yy.xxx (e.g. DPT_1001 --> 1.001) format.
+		name = name[4:len(name)-3] + "." + name[len(name)-3:]
+
+		registry[name] = d_type
+	}
+}
+
+// Register adds a user-defined datapoint-type under the given KNX name (e.g. "232.600") to the
+// shared registry, so that Produce and ListSupportedTypes pick it up alongside the built-in
+// types. Registering under a name that is already known overwrites the previous entry, which
+// lets integrators override a built-in DPT if they need to.
+//
+// prototype must be a non-nil pointer to a type implementing DatapointValue; its pointee type,
+// not the pointer you pass in, is what gets stored and later instantiated by Produce.
+func Register(name string, prototype DatapointValue) error {
+	if !registryNamePattern.MatchString(name) {
+		return fmt.Errorf("dpt: invalid datapoint-type name %q, expected format like \"1.001\"", name)
+	}
+
+	if prototype == nil {
+		return fmt.Errorf("dpt: prototype for %q must not be nil", name)
+	}
+
+	t := reflect.TypeOf(prototype)
+	if t.Kind() != reflect.Ptr {
+		return fmt.Errorf("dpt: prototype for %q must be a pointer, got %s", name, t.Kind())
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry[name] = t.Elem()
+
+	return nil
+}
+
+// MustRegister is like Register but panics instead of returning an error. It is meant for use
+// from an init function, where a malformed registration is a programming error.
+func MustRegister(name string, prototype DatapointValue) {
+	if err := Register(name, prototype); err != nil {
+		panic(err)
+	}
 }
 
-// ListSupportedTypes returns the name all known datapoint-types (DPTs).
+// Unregister removes a datapoint-type from the registry. It is a no-op if name is not known.
+func Unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	delete(registry, name)
+}
+
+// Lookup returns the concrete type registered under name, and whether it was found.
+func Lookup(name string) (reflect.Type, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	t, ok := registry[name]
+	return t, ok
+}
+
+// ListSupportedTypes returns the name of all known datapoint-types (DPTs), in a stable,
+// lexicographically sorted order.
 func ListSupportedTypes() []string {
-	// Setup the registry
-	setup()
+	registryMu.RLock()
+	defer registryMu.RUnlock()
 
-	// Initialize the key-list
-	keys := make([]string, len(registry))
+	// Initialize the key-list.
+	keys := make([]string, 0, len(registry))
 
-	// Fill the key-list
-	i := 0
+	// Fill the key-list.
 	for k := range registry {
-		keys[i] = k
-		i++
+		keys = append(keys, k)
 	}
 
+	sort.Strings(keys)
+
 	return keys
 }
 
 // Produce creates a new instance of the given datapoint-type name e.g. "1.001".
 func Produce(name string) (d DatapointValue, ok bool) {
-	// Setup the registry
-	setup()
-
-	// Lookup the given type and create a new instance of that type
-	x, ok := registry[name]
+	// Lookup the given type and create a new instance of that type.
+	t, ok := Lookup(name)
 	if ok {
-		d = reflect.New(x).Interface().(DatapointValue)
+		d = reflect.New(t).Interface().(DatapointValue)
 	}
+
 	return d, ok
 }
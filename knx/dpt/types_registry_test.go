@@ -0,0 +1,148 @@
+// Copyright 2020 Sven Rebhan.
+// Licensed under the MIT license which can be found in the LICENSE file.
+
+package dpt
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestRegisterValidatesName(t *testing.T) {
+	tests := []struct {
+		name string
+		dpt  string
+	}{
+		{"missing dot", "1001"},
+		{"too few digits", "1.01"},
+		{"too many digits", "1.0001"},
+		{"non-numeric major", "a.001"},
+		{"empty", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := Register(test.dpt, new(DPT_1001)); err == nil {
+				t.Fatalf("Register(%q, ...) = nil, want an error", test.dpt)
+			}
+		})
+	}
+}
+
+func TestRegisterRejectsNilPrototype(t *testing.T) {
+	if err := Register("250.600", nil); err == nil {
+		t.Fatal("Register(..., nil) = nil, want an error")
+	}
+}
+
+func TestRegisterUnregisterRoundTrip(t *testing.T) {
+	const name = "250.601"
+
+	if _, ok := Lookup(name); ok {
+		t.Fatalf("Lookup(%q) found an entry before Register", name)
+	}
+
+	if err := Register(name, new(DPT_5001)); err != nil {
+		t.Fatalf("Register(%q, ...) = %v, want nil", name, err)
+	}
+	defer Unregister(name)
+
+	got, ok := Lookup(name)
+	if !ok {
+		t.Fatalf("Lookup(%q) = _, false, want true after Register", name)
+	}
+
+	if want := reflect.TypeOf(DPT_5001{}); got != want {
+		t.Fatalf("Lookup(%q) = %v, want %v", name, got, want)
+	}
+
+	value, ok := Produce(name)
+	if !ok {
+		t.Fatalf("Produce(%q) = _, false, want true", name)
+	}
+
+	if _, ok := value.(*DPT_5001); !ok {
+		t.Fatalf("Produce(%q) returned %T, want *DPT_5001", name, value)
+	}
+
+	Unregister(name)
+
+	if _, ok := Lookup(name); ok {
+		t.Fatalf("Lookup(%q) found an entry after Unregister", name)
+	}
+
+	if _, ok := Produce(name); ok {
+		t.Fatalf("Produce(%q) succeeded after Unregister", name)
+	}
+}
+
+func TestRegisterOverwritesExistingName(t *testing.T) {
+	const name = "250.602"
+
+	if err := Register(name, new(DPT_5001)); err != nil {
+		t.Fatalf("Register(%q, DPT_5001) = %v, want nil", name, err)
+	}
+	defer Unregister(name)
+
+	if err := Register(name, new(DPT_9001)); err != nil {
+		t.Fatalf("Register(%q, DPT_9001) = %v, want nil", name, err)
+	}
+
+	got, ok := Lookup(name)
+	if !ok {
+		t.Fatalf("Lookup(%q) = _, false, want true", name)
+	}
+
+	if want := reflect.TypeOf(DPT_9001{}); got != want {
+		t.Fatalf("Lookup(%q) = %v, want %v after overwrite", name, got, want)
+	}
+}
+
+func TestUnregisterUnknownNameIsNoop(t *testing.T) {
+	Unregister("250.603")
+}
+
+func TestMustRegisterPanicsOnInvalidName(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("MustRegister did not panic on an invalid name")
+		}
+	}()
+
+	MustRegister("not-a-dpt-name", new(DPT_1001))
+}
+
+func TestListSupportedTypesIsSortedAndIncludesRegistrations(t *testing.T) {
+	const name = "250.604"
+
+	if err := Register(name, new(DPT_1001)); err != nil {
+		t.Fatalf("Register(%q, ...) = %v, want nil", name, err)
+	}
+	defer Unregister(name)
+
+	names := ListSupportedTypes()
+
+	if !sort.StringsAreSorted(names) {
+		t.Fatalf("ListSupportedTypes() = %v, want a sorted slice", names)
+	}
+
+	found := false
+	for _, n := range names {
+		if n == name {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		t.Fatalf("ListSupportedTypes() = %v, want it to contain %q", names, name)
+	}
+
+	// A few built-in types should always be present alongside user registrations.
+	for _, builtin := range []string{"1.001", "5.001", "9.001"} {
+		if _, ok := Lookup(builtin); !ok {
+			t.Fatalf("Lookup(%q) = _, false, want the built-in type to still be registered", builtin)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package knx
+
+import (
+	"context"
+	"sync"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/proto"
+)
+
+// fakeSocket is a minimal Socket used to exercise Tunnel logic without a real gateway. Send
+// always succeeds and records what was sent; Inbound is empty unless a test feeds it directly.
+type fakeSocket struct {
+	mu     sync.Mutex
+	sent   []interface{}
+	closed bool
+
+	inbound chan interface{}
+}
+
+func newFakeSocket() *fakeSocket {
+	return &fakeSocket{inbound: make(chan interface{})}
+}
+
+func (s *fakeSocket) Send(payload interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = append(s.sent, payload)
+
+	return nil
+}
+
+func (s *fakeSocket) Inbound() <-chan interface{} {
+	return s.inbound
+}
+
+func (s *fakeSocket) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+
+	return nil
+}
+
+// lastSent returns the most recently sent payload, or nil if nothing has been sent yet.
+func (s *fakeSocket) lastSent() interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.sent) == 0 {
+		return nil
+	}
+
+	return s.sent[len(s.sent)-1]
+}
+
+// hasSentDiscReq reports whether a *proto.DiscReq has been sent so far.
+func (s *fakeSocket) hasSentDiscReq() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, payload := range s.sent {
+		if _, ok := payload.(*proto.DiscReq); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newTestTunnel builds a Tunnel around sock without dialing a real gateway, so unit tests can
+// drive the tunnelConn/Tunnel logic directly.
+func newTestTunnel(sock Socket, config TunnelConfig) *Tunnel {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Tunnel{
+		tunnelConn: tunnelConn{
+			sock:      sock,
+			config:    checkTunnelConfig(config),
+			ack:       make(chan *proto.TunnelRes),
+			inbound:   make(chan cemi.Message),
+			shutdownC: make(chan struct{}),
+			serveDone: make(chan struct{}),
+		},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
@@ -0,0 +1,410 @@
+package knx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// PoolStrategy selects how TunnelPool picks the tunnel used for an outgoing Send.
+type PoolStrategy int
+
+const (
+	// RoundRobin cycles through the healthy tunnels in turn.
+	RoundRobin PoolStrategy = iota
+
+	// Primary always prefers the first gateway address the pool was given, falling back to the
+	// others, in the order given, while the first one is unhealthy or when a Send through it
+	// fails.
+	Primary
+
+	// LeastLoaded picks the healthy tunnel with the fewest errors observed recently.
+	LeastLoaded
+)
+
+// poolUnhealthyThreshold is how many consecutive Send errors mark a tunnel as unhealthy.
+const poolUnhealthyThreshold = 3
+
+// PoolConfig configures a TunnelPool.
+type PoolConfig struct {
+	// Strategy determines how Send picks a tunnel among the healthy ones. The zero value is
+	// RoundRobin.
+	Strategy PoolStrategy
+
+	// HealthInterval is how often the pool lets an unhealthy tunnel's error counter decay, so
+	// that a gateway which recovers is eventually considered for Send again. A value <= 0 will
+	// result in the use of a default value.
+	HealthInterval time.Duration
+
+	// FailoverTimeout bounds how long Send waits on one tunnel before giving up on it and
+	// retrying the next healthy one. A value <= 0 will result in the use of a default value.
+	FailoverTimeout time.Duration
+
+	// DedupWindow is how long the pool remembers a message it has already delivered on
+	// Inbound, so that the same telegram echoed by several gateways is only delivered once. A
+	// value <= 0 will result in the use of a default value.
+	DedupWindow time.Duration
+
+	// Tunnel is the configuration used for every tunnel the pool establishes.
+	Tunnel TunnelConfig
+}
+
+// Default pool configuration elements.
+var (
+	defaultPoolHealthInterval = 5 * time.Second
+	defaultFailoverTimeout    = 2 * time.Second
+	defaultDedupWindow        = time.Second
+)
+
+// checkPoolConfig makes sure that the configuration is actually usable.
+func checkPoolConfig(config PoolConfig) PoolConfig {
+	if config.HealthInterval <= 0 {
+		config.HealthInterval = defaultPoolHealthInterval
+	}
+
+	if config.FailoverTimeout <= 0 {
+		config.FailoverTimeout = defaultFailoverTimeout
+	}
+
+	if config.DedupWindow <= 0 {
+		config.DedupWindow = defaultDedupWindow
+	}
+
+	config.Tunnel = checkTunnelConfig(config.Tunnel)
+
+	return config
+}
+
+// ErrPoolUnavailable is returned by TunnelPool.Send when no member tunnel is currently healthy.
+var ErrPoolUnavailable = errors.New("knx: no healthy tunnel available in pool")
+
+// poolMember tracks one gateway connection held by a TunnelPool, along with the rolling error
+// counter that its health is derived from.
+type poolMember struct {
+	addr   string
+	tunnel *Tunnel
+
+	mu       sync.Mutex
+	healthy  bool
+	errCount int
+}
+
+// isHealthy reports whether member should still be considered for Send. A tunnel whose serve
+// loop has given up for good is never healthy again.
+func (member *poolMember) isHealthy() bool {
+	select {
+	case <-member.tunnel.serveDone:
+		return false
+	default:
+	}
+
+	// A tunnel whose heartbeat has already failed and is actively retrying the connection isn't
+	// usable yet, even though its error counter may still look fine.
+	if member.tunnel.isReconnecting() {
+		return false
+	}
+
+	member.mu.Lock()
+	defer member.mu.Unlock()
+
+	return member.healthy
+}
+
+// load returns the member's current error counter, used by the LeastLoaded strategy.
+func (member *poolMember) load() int {
+	member.mu.Lock()
+	defer member.mu.Unlock()
+
+	return member.errCount
+}
+
+func (member *poolMember) recordSuccess() {
+	member.mu.Lock()
+	defer member.mu.Unlock()
+
+	member.errCount = 0
+	member.healthy = true
+}
+
+func (member *poolMember) recordError() {
+	member.mu.Lock()
+	defer member.mu.Unlock()
+
+	member.errCount++
+
+	if member.errCount >= poolUnhealthyThreshold {
+		member.healthy = false
+	}
+}
+
+// decay lets a healthy-again tunnel recover from a past burst of errors over time.
+func (member *poolMember) decay() {
+	member.mu.Lock()
+	defer member.mu.Unlock()
+
+	if member.errCount > 0 {
+		member.errCount--
+	}
+
+	if member.errCount < poolUnhealthyThreshold {
+		member.healthy = true
+	}
+}
+
+// TunnelPool multiplexes several Tunnel connections to redundant gateways into a single
+// interface, so that the failure of one gateway does not interrupt the bus. Inbound merges the
+// inbound stream of every member tunnel, collapsing duplicate telegrams that several gateways
+// echo within the same short window. Send routes to one healthy member at a time according to
+// PoolConfig.Strategy, retrying the next healthy member if the chosen one errors or times out.
+type TunnelPool struct {
+	config  PoolConfig
+	members []*poolMember
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	inbound   chan cemi.Message
+	relayWg   sync.WaitGroup
+	closeOnce sync.Once
+
+	rrMu   sync.Mutex
+	rrNext int
+
+	dedupMu sync.Mutex
+	seen    map[string]time.Time
+}
+
+// NewTunnelPool establishes a Tunnel to every given gateway address and combines them into a
+// single TunnelPool. If any gateway cannot be reached, the tunnels already established are
+// closed and the error is returned.
+func NewTunnelPool(gatewayAddrs []string, config PoolConfig) (*TunnelPool, error) {
+	if len(gatewayAddrs) == 0 {
+		return nil, errors.New("knx: a tunnel pool needs at least one gateway address")
+	}
+
+	config = checkPoolConfig(config)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	pool := &TunnelPool{
+		config:  config,
+		ctx:     ctx,
+		cancel:  cancel,
+		inbound: make(chan cemi.Message),
+		seen:    make(map[string]time.Time),
+	}
+
+	for _, addr := range gatewayAddrs {
+		tunnel, err := NewTunnel(addr, config.Tunnel)
+		if err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("knx: connecting to gateway %q: %w", addr, err)
+		}
+
+		member := &poolMember{addr: addr, tunnel: tunnel, healthy: true}
+		pool.members = append(pool.members, member)
+
+		pool.relayWg.Add(1)
+		go pool.relay(member)
+	}
+
+	go pool.monitorHealth()
+
+	// Close the merged Inbound channel once every relay has stopped writing to it, mirroring
+	// the way a single Tunnel closes its own inbound channel once serve returns.
+	go pool.closeInboundWhenDrained()
+
+	return pool, nil
+}
+
+// closeInboundWhenDrained waits until every relay goroutine has returned, then closes the
+// merged Inbound channel so that a `for range pool.Inbound()` consumer terminates after Close.
+func (pool *TunnelPool) closeInboundWhenDrained() {
+	pool.relayWg.Wait()
+	pool.closeOnce.Do(func() {
+		close(pool.inbound)
+	})
+}
+
+// relay forwards member's inbound messages onto the pool's merged Inbound channel, dropping
+// duplicates seen recently on another member.
+func (pool *TunnelPool) relay(member *poolMember) {
+	defer pool.relayWg.Done()
+
+	for {
+		select {
+		case <-pool.ctx.Done():
+			return
+
+		case msg, open := <-member.tunnel.Inbound():
+			if !open {
+				return
+			}
+
+			if !pool.shouldDeliver(msg) {
+				continue
+			}
+
+			select {
+			case <-pool.ctx.Done():
+				return
+			case pool.inbound <- msg:
+			}
+		}
+	}
+}
+
+// shouldDeliver reports whether msg has not already been delivered within the configured
+// DedupWindow, recording it as seen as a side effect. The dedup key is the packed frame itself,
+// not a %#v dump of msg, since a Go representation of a message containing pointer fields would
+// render as the address rather than the payload and fail to collapse an echoed telegram.
+func (pool *TunnelPool) shouldDeliver(msg cemi.Message) bool {
+	buffer := make([]byte, msg.Size())
+	msg.Pack(buffer)
+
+	return pool.recordSeen(string(buffer), time.Now())
+}
+
+// recordSeen is the dedup decision itself, factored out of shouldDeliver so it can be tested
+// without needing a real cemi.Message: it reports whether key has not already been seen within
+// DedupWindow of now, recording it as seen as a side effect.
+func (pool *TunnelPool) recordSeen(key string, now time.Time) bool {
+	pool.dedupMu.Lock()
+	defer pool.dedupMu.Unlock()
+
+	if last, ok := pool.seen[key]; ok && now.Sub(last) < pool.config.DedupWindow {
+		return false
+	}
+
+	pool.seen[key] = now
+
+	// Opportunistically forget stale entries so the map doesn't grow without bound.
+	for k, t := range pool.seen {
+		if now.Sub(t) > pool.config.DedupWindow {
+			delete(pool.seen, k)
+		}
+	}
+
+	return true
+}
+
+// monitorHealth periodically lets member error counters decay, so that a gateway which has
+// recovered becomes eligible for Send again.
+func (pool *TunnelPool) monitorHealth() {
+	ticker := time.NewTicker(pool.config.HealthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pool.ctx.Done():
+			return
+
+		case <-ticker.C:
+			for _, member := range pool.members {
+				member.decay()
+			}
+		}
+	}
+}
+
+// pickOrder returns the members in the order Send should try them, per PoolConfig.Strategy.
+func (pool *TunnelPool) pickOrder() []*poolMember {
+	switch pool.config.Strategy {
+	case Primary:
+		ordered := make([]*poolMember, len(pool.members))
+		copy(ordered, pool.members)
+		return ordered
+
+	case LeastLoaded:
+		ordered := make([]*poolMember, len(pool.members))
+		copy(ordered, pool.members)
+		sort.Slice(ordered, func(i, j int) bool {
+			return ordered[i].load() < ordered[j].load()
+		})
+		return ordered
+
+	default: // RoundRobin
+		pool.rrMu.Lock()
+		start := pool.rrNext
+		pool.rrNext = (pool.rrNext + 1) % len(pool.members)
+		pool.rrMu.Unlock()
+
+		ordered := make([]*poolMember, len(pool.members))
+		for i := range pool.members {
+			ordered[i] = pool.members[(start+i)%len(pool.members)]
+		}
+		return ordered
+	}
+}
+
+// Inbound retrieves the channel which transmits the merged, deduplicated inbound data of every
+// tunnel in the pool.
+func (pool *TunnelPool) Inbound() <-chan cemi.Message {
+	return pool.inbound
+}
+
+// Send relays data through one healthy tunnel in the pool, retrying the next healthy tunnel if
+// the chosen one errors or times out. It returns ErrPoolUnavailable if no tunnel is healthy.
+func (pool *TunnelPool) Send(data cemi.Message) error {
+	return pool.SendContext(pool.ctx, data)
+}
+
+// SendContext is like Send, but threads ctx down into the chosen tunnel's SendContext. Each
+// attempted tunnel is additionally bounded by PoolConfig.FailoverTimeout, so a gateway that
+// merely hangs doesn't stall failover to the next one.
+func (pool *TunnelPool) SendContext(ctx context.Context, data cemi.Message) error {
+	var lastErr error
+	tried := false
+
+	for _, member := range pool.pickOrder() {
+		// The caller's context dying is not the tunnel's fault: bail out immediately instead of
+		// charging every remaining member an error for a failure that isn't theirs.
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !member.isHealthy() {
+			continue
+		}
+
+		tried = true
+
+		attemptCtx, cancel := context.WithTimeout(ctx, pool.config.FailoverTimeout)
+		err := member.tunnel.SendContext(attemptCtx, data)
+		cancel()
+
+		if err == nil {
+			member.recordSuccess()
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		member.recordError()
+		lastErr = err
+
+		log(pool, "pool", "Send via %s failed, trying next tunnel: %v", member.addr, err)
+	}
+
+	if !tried {
+		return ErrPoolUnavailable
+	}
+
+	return lastErr
+}
+
+// Close terminates every tunnel in the pool and stops relaying inbound messages.
+func (pool *TunnelPool) Close() {
+	pool.cancel()
+
+	for _, member := range pool.members {
+		member.tunnel.Close()
+	}
+}
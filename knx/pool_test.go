@@ -0,0 +1,208 @@
+package knx
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/proto"
+)
+
+// newPoolTestMember wraps a fresh fakeSocket-backed Tunnel into a healthy poolMember, without
+// going through NewTunnelPool's real dialing.
+func newPoolTestMember(addr string, config TunnelConfig) (*poolMember, *fakeSocket) {
+	sock := newFakeSocket()
+	tunnel := newTestTunnel(sock, config)
+
+	return &poolMember{addr: addr, tunnel: tunnel, healthy: true}, sock
+}
+
+func TestPickOrderRoundRobinCycles(t *testing.T) {
+	m1, _ := newPoolTestMember("a", TunnelConfig{})
+	m2, _ := newPoolTestMember("b", TunnelConfig{})
+	m3, _ := newPoolTestMember("c", TunnelConfig{})
+
+	pool := &TunnelPool{
+		config:  checkPoolConfig(PoolConfig{Strategy: RoundRobin}),
+		members: []*poolMember{m1, m2, m3},
+	}
+
+	first := pool.pickOrder()
+	second := pool.pickOrder()
+
+	if first[0] != m1 || first[1] != m2 || first[2] != m3 {
+		t.Fatalf("pickOrder() first = %v, want [m1 m2 m3]", first)
+	}
+
+	if second[0] != m2 || second[1] != m3 || second[2] != m1 {
+		t.Fatalf("pickOrder() second = %v, want [m2 m3 m1]", second)
+	}
+}
+
+func TestPickOrderPrimaryAlwaysLeadsWithFirst(t *testing.T) {
+	m1, _ := newPoolTestMember("a", TunnelConfig{})
+	m2, _ := newPoolTestMember("b", TunnelConfig{})
+
+	pool := &TunnelPool{
+		config:  checkPoolConfig(PoolConfig{Strategy: Primary}),
+		members: []*poolMember{m1, m2},
+	}
+
+	for i := 0; i < 3; i++ {
+		order := pool.pickOrder()
+		if order[0] != m1 || order[1] != m2 {
+			t.Fatalf("pickOrder() = %v, want [m1 m2] every time", order)
+		}
+	}
+}
+
+func TestPickOrderLeastLoadedOrdersByErrorCount(t *testing.T) {
+	m1, _ := newPoolTestMember("a", TunnelConfig{})
+	m2, _ := newPoolTestMember("b", TunnelConfig{})
+	m3, _ := newPoolTestMember("c", TunnelConfig{})
+
+	m1.recordError()
+	m1.recordError()
+	m3.recordError()
+
+	pool := &TunnelPool{
+		config:  checkPoolConfig(PoolConfig{Strategy: LeastLoaded}),
+		members: []*poolMember{m1, m2, m3},
+	}
+
+	order := pool.pickOrder()
+	if order[0] != m2 || order[1] != m3 || order[2] != m1 {
+		t.Fatalf("pickOrder() = %v, want [m2 m3 m1] ordered by ascending load", order)
+	}
+}
+
+func TestPoolMemberHealthCrossesUnhealthyThreshold(t *testing.T) {
+	member, _ := newPoolTestMember("a", TunnelConfig{})
+
+	for i := 0; i < poolUnhealthyThreshold-1; i++ {
+		member.recordError()
+	}
+
+	if !member.isHealthy() {
+		t.Fatal("isHealthy() = false before reaching poolUnhealthyThreshold, want true")
+	}
+
+	member.recordError()
+
+	if member.isHealthy() {
+		t.Fatal("isHealthy() = true at poolUnhealthyThreshold, want false")
+	}
+
+	member.recordSuccess()
+
+	if !member.isHealthy() {
+		t.Fatal("isHealthy() = false after recordSuccess, want true")
+	}
+}
+
+func TestPoolMemberDecayRecoversGradually(t *testing.T) {
+	member, _ := newPoolTestMember("a", TunnelConfig{})
+
+	for i := 0; i < poolUnhealthyThreshold; i++ {
+		member.recordError()
+	}
+
+	if member.isHealthy() {
+		t.Fatal("isHealthy() = true right after reaching poolUnhealthyThreshold, want false")
+	}
+
+	for i := 0; i < poolUnhealthyThreshold; i++ {
+		member.decay()
+	}
+
+	if !member.isHealthy() {
+		t.Fatal("isHealthy() = false after decaying below poolUnhealthyThreshold, want true")
+	}
+}
+
+func TestPoolMemberUnhealthyWhileServeDone(t *testing.T) {
+	member, _ := newPoolTestMember("a", TunnelConfig{})
+	close(member.tunnel.serveDone)
+
+	if member.isHealthy() {
+		t.Fatal("isHealthy() = true with serveDone closed, want false")
+	}
+}
+
+func TestPoolMemberUnhealthyWhileReconnecting(t *testing.T) {
+	member, _ := newPoolTestMember("a", TunnelConfig{})
+	atomic.StoreInt32(&member.tunnel.reconnecting, 1)
+
+	if member.isHealthy() {
+		t.Fatal("isHealthy() = true while the tunnel is reconnecting, want false")
+	}
+}
+
+func TestRecordSeenDeduplicatesWithinWindow(t *testing.T) {
+	pool := &TunnelPool{
+		config: checkPoolConfig(PoolConfig{DedupWindow: time.Minute}),
+		seen:   make(map[string]time.Time),
+	}
+
+	now := time.Now()
+
+	if !pool.recordSeen("key", now) {
+		t.Fatal("recordSeen() = false on first sighting, want true")
+	}
+
+	if pool.recordSeen("key", now.Add(time.Second)) {
+		t.Fatal("recordSeen() = true for a repeat within DedupWindow, want false")
+	}
+
+	if !pool.recordSeen("key", now.Add(2*time.Minute)) {
+		t.Fatal("recordSeen() = false once DedupWindow has elapsed, want true")
+	}
+}
+
+func TestTunnelPoolSendContextFailsOverToHealthyMember(t *testing.T) {
+	config := TunnelConfig{ResponseTimeout: time.Hour}
+
+	stuck, stuckSock := newPoolTestMember("stuck", config)
+	ok, okSock := newPoolTestMember("ok", config)
+
+	pool := &TunnelPool{
+		config:  checkPoolConfig(PoolConfig{Strategy: Primary, FailoverTimeout: 10 * time.Millisecond}),
+		members: []*poolMember{stuck, ok},
+	}
+
+	// Acknowledge whatever the healthy member sends, so its SendContext can actually succeed.
+	go func() {
+		ok.tunnel.ack <- &proto.TunnelRes{SeqNumber: 0, Status: 0}
+	}()
+
+	if err := pool.SendContext(context.Background(), nil); err != nil {
+		t.Fatalf("SendContext() = %v, want nil after failing over to the healthy member", err)
+	}
+
+	if stuckSock.lastSent() == nil {
+		t.Fatal("SendContext() never attempted the first member before failing over")
+	}
+
+	if okSock.lastSent() == nil {
+		t.Fatal("SendContext() did not reach the healthy member")
+	}
+
+	if stuck.load() == 0 {
+		t.Fatal("the unresponsive member's error counter was not incremented")
+	}
+}
+
+func TestTunnelPoolSendContextReturnsErrPoolUnavailable(t *testing.T) {
+	member, _ := newPoolTestMember("a", TunnelConfig{})
+	member.healthy = false
+
+	pool := &TunnelPool{
+		config:  checkPoolConfig(PoolConfig{}),
+		members: []*poolMember{member},
+	}
+
+	if err := pool.SendContext(context.Background(), nil); err != ErrPoolUnavailable {
+		t.Fatalf("SendContext() = %v, want ErrPoolUnavailable", err)
+	}
+}
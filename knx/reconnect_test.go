@@ -0,0 +1,270 @@
+package knx
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/proto"
+)
+
+func TestCheckTunnelConfigFillsDefaults(t *testing.T) {
+	config := checkTunnelConfig(TunnelConfig{})
+
+	if config.ResendInterval != defaultResendInterval {
+		t.Errorf("ResendInterval = %v, want %v", config.ResendInterval, defaultResendInterval)
+	}
+
+	if config.HeartbeatInterval != defaultHeartbeatInterval {
+		t.Errorf("HeartbeatInterval = %v, want %v", config.HeartbeatInterval, defaultHeartbeatInterval)
+	}
+
+	if config.ResponseTimeout != defaultResponseTimeout {
+		t.Errorf("ResponseTimeout = %v, want %v", config.ResponseTimeout, defaultResponseTimeout)
+	}
+
+	if config.ShutdownGracePeriod != defaultShutdownGracePeriod {
+		t.Errorf("ShutdownGracePeriod = %v, want %v", config.ShutdownGracePeriod, defaultShutdownGracePeriod)
+	}
+
+	if config.Layer != proto.TunnelLayerData {
+		t.Errorf("Layer = %v, want %v", config.Layer, proto.TunnelLayerData)
+	}
+
+	if config.Reconnect.InitialBackoff != defaultInitialBackoff {
+		t.Errorf("Reconnect.InitialBackoff = %v, want %v", config.Reconnect.InitialBackoff, defaultInitialBackoff)
+	}
+}
+
+func TestCheckTunnelConfigPreservesBusmonLayer(t *testing.T) {
+	config := checkTunnelConfig(TunnelConfig{Layer: proto.TunnelLayerBusmon})
+
+	if config.Layer != proto.TunnelLayerBusmon {
+		t.Errorf("Layer = %v, want %v", config.Layer, proto.TunnelLayerBusmon)
+	}
+}
+
+func TestCheckReconnectPolicyFillsDefaults(t *testing.T) {
+	policy := checkReconnectPolicy(ReconnectPolicy{})
+
+	if policy.InitialBackoff != defaultInitialBackoff {
+		t.Errorf("InitialBackoff = %v, want %v", policy.InitialBackoff, defaultInitialBackoff)
+	}
+
+	if policy.MaxBackoff != defaultMaxBackoff {
+		t.Errorf("MaxBackoff = %v, want %v", policy.MaxBackoff, defaultMaxBackoff)
+	}
+
+	if policy.BackoffFactor != defaultBackoffFactor {
+		t.Errorf("BackoffFactor = %v, want %v", policy.BackoffFactor, defaultBackoffFactor)
+	}
+
+	if policy.StabilityWindow != defaultStabilityWindow {
+		t.Errorf("StabilityWindow = %v, want %v", policy.StabilityWindow, defaultStabilityWindow)
+	}
+
+	if policy.Jitter != 0 {
+		t.Errorf("Jitter = %v, want 0", policy.Jitter)
+	}
+}
+
+func TestCheckReconnectPolicyPreservesExplicitValues(t *testing.T) {
+	want := ReconnectPolicy{
+		MaxAttempts:     5,
+		InitialBackoff:  10 * time.Millisecond,
+		MaxBackoff:      time.Second,
+		BackoffFactor:   3,
+		Jitter:          0.5,
+		StabilityWindow: time.Minute,
+	}
+
+	got := checkReconnectPolicy(want)
+
+	switch {
+	case got.MaxAttempts != want.MaxAttempts,
+		got.InitialBackoff != want.InitialBackoff,
+		got.MaxBackoff != want.MaxBackoff,
+		got.BackoffFactor != want.BackoffFactor,
+		got.Jitter != want.Jitter,
+		got.StabilityWindow != want.StabilityWindow:
+		t.Errorf("checkReconnectPolicy(%+v) = %+v, want it unchanged", want, got)
+	}
+}
+
+func TestCheckReconnectPolicyRejectsNegativeJitter(t *testing.T) {
+	policy := checkReconnectPolicy(ReconnectPolicy{Jitter: -1})
+
+	if policy.Jitter != 0 {
+		t.Errorf("Jitter = %v, want 0 for a negative input", policy.Jitter)
+	}
+}
+
+func TestBackoffDelayWithoutJitter(t *testing.T) {
+	if got := backoffDelay(time.Second, 0); got != time.Second {
+		t.Errorf("backoffDelay(1s, 0) = %v, want 1s", got)
+	}
+}
+
+func TestBackoffDelayWithJitterStaysBounded(t *testing.T) {
+	const delay = 100 * time.Millisecond
+	const jitter = 0.5
+
+	for i := 0; i < 100; i++ {
+		got := backoffDelay(delay, jitter)
+
+		if got < delay {
+			t.Fatalf("backoffDelay(%v, %v) = %v, want >= %v", delay, jitter, got, delay)
+		}
+
+		if max := delay + time.Duration(float64(delay)*jitter); got > max {
+			t.Fatalf("backoffDelay(%v, %v) = %v, want <= %v", delay, jitter, got, max)
+		}
+	}
+}
+
+// reconnectRecorder collects the (attempt, err) pairs a ReconnectPolicy.OnReconnect hook was
+// called with, and lets a test block until the Nth call has happened.
+type reconnectRecorder struct {
+	mu     sync.Mutex
+	calls  []error
+	notify chan struct{}
+}
+
+func newReconnectRecorder() *reconnectRecorder {
+	return &reconnectRecorder{notify: make(chan struct{}, 64)}
+}
+
+func (r *reconnectRecorder) onReconnect(attempt int, err error) {
+	r.mu.Lock()
+	r.calls = append(r.calls, err)
+	r.mu.Unlock()
+
+	r.notify <- struct{}{}
+}
+
+func (r *reconnectRecorder) len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.calls)
+}
+
+// awaitCall blocks until the recorder has observed n calls in total, or fails the test.
+func (r *reconnectRecorder) awaitCall(t *testing.T, n int) {
+	t.Helper()
+
+	for r.len() < n {
+		select {
+		case <-r.notify:
+		case <-time.After(time.Second):
+			t.Fatalf("OnReconnect was not called a %dth time within 1s", r.len()+1)
+		}
+	}
+}
+
+// newReconnectTestConn builds a tunnelConn around a fresh fakeSocket, configured to reconnect
+// quickly, so serve's reconnect loop can be driven directly without a real gateway.
+func newReconnectTestConn(policy ReconnectPolicy) (*tunnelConn, *fakeSocket) {
+	sock := newFakeSocket()
+
+	config := checkTunnelConfig(TunnelConfig{
+		ResendInterval:    time.Millisecond,
+		ResponseTimeout:   20 * time.Millisecond,
+		HeartbeatInterval: time.Hour,
+		Reconnect:         policy,
+	})
+
+	conn := &tunnelConn{
+		sock:      sock,
+		config:    config,
+		ack:       make(chan *proto.TunnelRes),
+		inbound:   make(chan cemi.Message),
+		shutdownC: make(chan struct{}),
+		serveDone: make(chan struct{}),
+	}
+
+	return conn, sock
+}
+
+func TestServeReconnectGivesUpAfterMaxAttempts(t *testing.T) {
+	recorder := newReconnectRecorder()
+
+	conn, sock := newReconnectTestConn(ReconnectPolicy{
+		MaxAttempts:     3,
+		InitialBackoff:  time.Millisecond,
+		MaxBackoff:      2 * time.Millisecond,
+		BackoffFactor:   2,
+		StabilityWindow: time.Hour,
+		OnReconnect:     recorder.onReconnect,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go conn.serve(ctx)
+
+	// Trigger a graceful disconnect from the gateway's side, which process() turns into
+	// errDisconnected and hands off to the reconnect loop.
+	sock.inbound <- &proto.DiscReq{Channel: conn.channel}
+
+	recorder.awaitCall(t, 3)
+
+	select {
+	case <-conn.serveDone:
+	case <-time.After(time.Second):
+		t.Fatal("serve did not give up and return after exhausting MaxAttempts")
+	}
+
+	if got := recorder.len(); got != 3 {
+		t.Fatalf("OnReconnect was called %d times, want 3", got)
+	}
+
+	for i, err := range recorder.calls {
+		if err == nil {
+			t.Errorf("OnReconnect call %d: err = nil, want a failure since no ConnRes was ever sent", i+1)
+		}
+	}
+}
+
+func TestServeReconnectSucceedsAndResumesProcessing(t *testing.T) {
+	recorder := newReconnectRecorder()
+
+	conn, sock := newReconnectTestConn(ReconnectPolicy{
+		MaxAttempts:     0,
+		InitialBackoff:  time.Millisecond,
+		MaxBackoff:      2 * time.Millisecond,
+		BackoffFactor:   2,
+		StabilityWindow: time.Hour,
+		OnReconnect:     recorder.onReconnect,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go conn.serve(ctx)
+
+	sock.inbound <- &proto.DiscReq{Channel: conn.channel}
+
+	// Let the first reconnect attempt fail (no ConnRes is sent for it), then answer the second
+	// one so the tunnel comes back up.
+	recorder.awaitCall(t, 1)
+	sock.inbound <- &proto.ConnRes{Status: proto.ConnResOk, Channel: 7}
+
+	recorder.awaitCall(t, 2)
+
+	select {
+	case <-conn.serveDone:
+		t.Fatal("serve returned after a successful reconnect, want it to resume processing")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if got := recorder.calls[1]; got != nil {
+		t.Fatalf("OnReconnect second call: err = %v, want nil after ConnRes confirmed the reconnect", got)
+	}
+
+	if conn.isReconnecting() {
+		t.Fatal("isReconnecting() = true after a successful reconnect, want false")
+	}
+}
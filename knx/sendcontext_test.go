@@ -0,0 +1,74 @@
+package knx
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSendContextRespectsCallerCancellation(t *testing.T) {
+	client := newTestTunnel(newFakeSocket(), TunnelConfig{ResponseTimeout: time.Second})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := client.SendContext(ctx, nil); err == nil {
+		t.Fatal("SendContext() = nil, want an error after the caller cancelled ctx")
+	}
+}
+
+func TestSendContextAppliesResponseTimeout(t *testing.T) {
+	client := newTestTunnel(newFakeSocket(), TunnelConfig{ResponseTimeout: 5 * time.Millisecond})
+
+	start := time.Now()
+	err := client.SendContext(context.Background(), nil)
+
+	if err == nil {
+		t.Fatal("SendContext() = nil, want a timeout error since no acknowledgement ever arrives")
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("SendContext() took %v, want it bounded by ResponseTimeout", elapsed)
+	}
+}
+
+func TestSendCallsSendContextWithClientContext(t *testing.T) {
+	client := newTestTunnel(newFakeSocket(), TunnelConfig{ResponseTimeout: 5 * time.Millisecond})
+	client.cancel()
+
+	if err := client.Send(nil); err == nil {
+		t.Fatal("Send() = nil, want an error once the tunnel's own context is cancelled")
+	}
+}
+
+func TestInboundContextReceivesMessage(t *testing.T) {
+	client := newTestTunnel(newFakeSocket(), TunnelConfig{})
+
+	go func() {
+		client.inbound <- nil
+	}()
+
+	if _, err := client.InboundContext(context.Background()); err != nil {
+		t.Fatalf("InboundContext() error = %v, want nil", err)
+	}
+}
+
+func TestInboundContextRespectsCancellation(t *testing.T) {
+	client := newTestTunnel(newFakeSocket(), TunnelConfig{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := client.InboundContext(ctx); err != context.Canceled {
+		t.Fatalf("InboundContext() error = %v, want context.Canceled", err)
+	}
+}
+
+func TestInboundContextReportsClosedChannel(t *testing.T) {
+	client := newTestTunnel(newFakeSocket(), TunnelConfig{})
+	close(client.inbound)
+
+	if _, err := client.InboundContext(context.Background()); err != errInboundClosed {
+		t.Fatalf("InboundContext() error = %v, want errInboundClosed", err)
+	}
+}
@@ -0,0 +1,82 @@
+package knx
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestShutdownWaitsForInFlightSendBeforeDisconnecting(t *testing.T) {
+	sock := newFakeSocket()
+	client := newTestTunnel(sock, TunnelConfig{ShutdownGracePeriod: 10 * time.Millisecond})
+
+	// Simulate a Send that is already in flight.
+	client.sendWg.Add(1)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- client.Shutdown(context.Background())
+	}()
+
+	select {
+	case <-result:
+		t.Fatal("Shutdown returned before the in-flight send finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if sock.hasSentDiscReq() {
+		t.Fatal("Shutdown sent a disconnect request before the in-flight send finished")
+	}
+
+	// New Send calls must be rejected once shutdown has begun.
+	if err := client.Send(nil); err != ErrShuttingDown {
+		t.Fatalf("Send() during Shutdown = %v, want ErrShuttingDown", err)
+	}
+
+	client.sendWg.Done()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("Shutdown() = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown did not return after the in-flight send finished")
+	}
+
+	if !sock.hasSentDiscReq() {
+		t.Fatal("Shutdown did not send a disconnect request")
+	}
+}
+
+func TestShutdownFallsBackToCloseWhenContextExpires(t *testing.T) {
+	sock := newFakeSocket()
+	client := newTestTunnel(sock, TunnelConfig{ShutdownGracePeriod: time.Minute})
+
+	// Hold an in-flight send open forever, so Shutdown can only make progress via ctx.
+	client.sendWg.Add(1)
+	defer client.sendWg.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := client.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Shutdown() = %v, want context.DeadlineExceeded", err)
+	}
+
+	if !sock.closed {
+		t.Fatal("Shutdown did not fall back to closing the socket once the context expired")
+	}
+}
+
+func TestShutdownIsIdempotent(t *testing.T) {
+	sock := newFakeSocket()
+	client := newTestTunnel(sock, TunnelConfig{ShutdownGracePeriod: 10 * time.Millisecond})
+
+	if err := client.Shutdown(context.Background()); err != nil {
+		t.Fatalf("first Shutdown() = %v, want nil", err)
+	}
+
+	// beginShutdown must tolerate being triggered twice without panicking on a double close.
+	client.beginShutdown()
+}
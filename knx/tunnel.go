@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/vapourismo/knx-go/knx/cemi"
@@ -24,18 +26,87 @@ type TunnelConfig struct {
 	// ResponseTimeout specifies how long to wait for a response. A timeout <= 0 will not be
 	// accepted. Instead, the default value will be used.
 	ResponseTimeout time.Duration
+
+	// ShutdownGracePeriod bounds how long Shutdown will wait for the serve loop to drain
+	// buffered inbound messages to the consumer and complete the disconnect handshake before
+	// the connection is torn down unconditionally. A value <= 0 will result in the use of a
+	// default value.
+	ShutdownGracePeriod time.Duration
+
+	// Reconnect controls how the tunnel retries the connection after a heartbeat failure or an
+	// unsolicited disconnect. Zero-valued fields are filled in with the defaults used by
+	// DefaultReconnectPolicy, except Jitter: a zero Jitter stays 0, i.e. no jitter is added.
+	Reconnect ReconnectPolicy
+
+	// Layer selects the tunnel connection type requested from the gateway. The zero value is
+	// proto.TunnelLayerData. Use proto.TunnelLayerBusmon, or the NewBusMonitor constructor, to
+	// open a read-only bus-monitor tunnel instead of participating in the bus as a device.
+	//
+	// TODO: cemi does not yet decode L_Busmon.ind frames, so until that decoder is added, these
+	// frames arrive on Inbound as *cemi.UnsupportedMessage rather than a typed indication. This is
+	// a known, unresolved limitation of bus-monitor support, not an implementation detail.
+	Layer proto.TunnelLayer
+}
+
+// ReconnectPolicy configures how a Tunnel retries a lost connection to the gateway.
+type ReconnectPolicy struct {
+	// MaxAttempts caps how many consecutive reconnect attempts are made after the connection is
+	// lost. 0 means the tunnel keeps retrying indefinitely.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the second reconnect attempt, i.e. after the first one
+	// has failed. A value <= 0 will result in the use of a default value.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between reconnect attempts. A value <= 0 will result in the use
+	// of a default value.
+	MaxBackoff time.Duration
+
+	// BackoffFactor is the multiplier applied to the backoff delay after every failed attempt.
+	// A value <= 1 will result in the use of a default value.
+	BackoffFactor float64
+
+	// Jitter is the fraction of the computed backoff, in the range [0, 1], that is added on top
+	// as uniform random jitter, to avoid reconnect storms when many tunnels drop at once.
+	Jitter float64
+
+	// StabilityWindow is how long a reconnected tunnel has to stay up before the attempt counter
+	// and backoff are reset. A value <= 0 will result in the use of a default value.
+	StabilityWindow time.Duration
+
+	// OnReconnect, if set, is invoked after every reconnect attempt with the attempt number
+	// (starting at 1) and the error returned by that attempt (nil on success). It is meant for
+	// logging and metrics, not control flow.
+	OnReconnect func(attempt int, err error)
 }
 
 // Default configuration elements
 var (
-	defaultResendInterval    = 500 * time.Millisecond
-	defaultHeartbeatInterval = 10 * time.Second
-	defaultResponseTimeout   = 10 * time.Second
+	defaultResendInterval      = 500 * time.Millisecond
+	defaultHeartbeatInterval   = 10 * time.Second
+	defaultResponseTimeout     = 10 * time.Second
+	defaultShutdownGracePeriod = 2 * time.Second
+	defaultInitialBackoff      = 1 * time.Second
+	defaultMaxBackoff          = 30 * time.Second
+	defaultBackoffFactor       = 2.0
+	defaultStabilityWindow     = 30 * time.Second
+
+	DefaultReconnectPolicy = ReconnectPolicy{
+		MaxAttempts:     0,
+		InitialBackoff:  defaultInitialBackoff,
+		MaxBackoff:      defaultMaxBackoff,
+		BackoffFactor:   defaultBackoffFactor,
+		Jitter:          0.2,
+		StabilityWindow: defaultStabilityWindow,
+	}
 
 	DefaultTunnelConfig = TunnelConfig{
 		defaultResendInterval,
 		defaultHeartbeatInterval,
 		defaultResponseTimeout,
+		defaultShutdownGracePeriod,
+		DefaultReconnectPolicy,
+		proto.TunnelLayerData,
 	}
 )
 
@@ -53,9 +124,53 @@ func checkTunnelConfig(config TunnelConfig) TunnelConfig {
 		config.ResponseTimeout = defaultResponseTimeout
 	}
 
+	if config.ShutdownGracePeriod <= 0 {
+		config.ShutdownGracePeriod = defaultShutdownGracePeriod
+	}
+
+	config.Reconnect = checkReconnectPolicy(config.Reconnect)
+
+	if config.Layer == 0 {
+		config.Layer = proto.TunnelLayerData
+	}
+
 	return config
 }
 
+// checkReconnectPolicy makes sure that the reconnect policy is actually usable.
+func checkReconnectPolicy(policy ReconnectPolicy) ReconnectPolicy {
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = defaultInitialBackoff
+	}
+
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = defaultMaxBackoff
+	}
+
+	if policy.BackoffFactor <= 1 {
+		policy.BackoffFactor = defaultBackoffFactor
+	}
+
+	if policy.Jitter < 0 {
+		policy.Jitter = 0
+	}
+
+	if policy.StabilityWindow <= 0 {
+		policy.StabilityWindow = defaultStabilityWindow
+	}
+
+	return policy
+}
+
+// backoffDelay adds uniform jitter, in the range [0, jitter*delay], on top of delay.
+func backoffDelay(delay time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 || delay <= 0 {
+		return delay
+	}
+
+	return delay + time.Duration(rand.Float64()*jitter*float64(delay))
+}
+
 // tunnelConn is a handle for a tunnel connection.
 type tunnelConn struct {
 	sock      Socket
@@ -66,13 +181,49 @@ type tunnelConn struct {
 	seqNumber uint8
 	ack       chan *proto.TunnelRes
 	inbound   chan cemi.Message
+
+	// reqWg tracks handleTunnelReq's delivery goroutines that are currently trying to send on
+	// inbound, so that serve can wait for them to finish before closing that channel.
+	reqWg sync.WaitGroup
+
+	// sendWg tracks Send/SendContext calls that are currently in flight, so that a graceful
+	// shutdown can wait for them to finish instead of aborting them.
+	sendWg sync.WaitGroup
+
+	// shutdownMu guards shutdownC, so that Send cannot start tracking itself in sendWg after
+	// Shutdown has already begun waiting on it.
+	shutdownMu sync.RWMutex
+	shutdownC  chan struct{}
+
+	// serveDone is closed once the serve loop has returned for good, i.e. it won't attempt to
+	// reconnect again.
+	serveDone chan struct{}
+
+	// reconnecting is 1 while serve is actively retrying a lost connection, and 0 otherwise.
+	// It complements serveDone as a liveness signal: a tunnel can be neither dead nor currently
+	// usable while it's between the heartbeat failure and a successful reconnect.
+	reconnecting int32
+}
+
+// isReconnecting reports whether the serve loop is currently retrying a lost connection.
+func (conn *tunnelConn) isReconnecting() bool {
+	return atomic.LoadInt32(&conn.reconnecting) != 0
 }
 
+// ErrShuttingDown is returned by Send and SendContext once Shutdown has been called, instead of
+// attempting to relay the message to a connection that is going away.
+var ErrShuttingDown = errors.New("knx: tunnel is shutting down")
+
+// ErrBusmonReadOnly is returned by Send and SendContext on a tunnel opened with
+// proto.TunnelLayerBusmon, e.g. through NewBusMonitor. A bus-monitor tunnel only observes the
+// bus; it cannot inject telegrams onto it.
+var ErrBusmonReadOnly = errors.New("knx: tunnel is a read-only bus monitor")
+
 // requestConn repeatedly sends a connection request through the socket until the provided context gets
 // canceled, or a response is received. A response that renders the gateway as busy will not stop
 // requestConn.
 func (conn *tunnelConn) requestConn(ctx context.Context) (err error) {
-	req := &proto.ConnReq{Layer: proto.TunnelLayerData}
+	req := &proto.ConnReq{Layer: conn.config.Layer}
 
 	// Send the initial request.
 	err = conn.sock.Send(req)
@@ -318,8 +469,13 @@ func (conn *tunnelConn) handleTunnelReq(
 	if req.SeqNumber == expected {
 		*seqNumber++
 
-		// Send tunnel data to the client.
+		// Send tunnel data to the client. reqWg lets serve wait for this delivery to finish
+		// (successfully or via ctx.Done()) before it closes conn.inbound, so this send can never
+		// race a close of that channel.
+		conn.reqWg.Add(1)
 		go func() {
+			defer conn.reqWg.Done()
+
 			select {
 			case <-ctx.Done():
 			case conn.inbound <- req.Payload:
@@ -386,9 +542,10 @@ func (conn *tunnelConn) handleConnStateRes(
 }
 
 var (
-	errHeartbeatFailed = errors.New("Heartbeat did not succeed")
-	errInboundClosed   = errors.New("Socket's inbound channel is closed")
-	errDisconnected    = errors.New("Gateway terminated the connection")
+	errHeartbeatFailed            = errors.New("Heartbeat did not succeed")
+	errInboundClosed              = errors.New("Socket's inbound channel is closed")
+	errDisconnected               = errors.New("Gateway terminated the connection")
+	errReconnectAttemptsExhausted = errors.New("Reconnect policy does not allow any attempts")
 )
 
 // process processes incoming packets.
@@ -467,32 +624,95 @@ func (conn *tunnelConn) process(ctx context.Context) error {
 }
 
 // serve serves the tunnel connection. It can sustain certain failures. This function will try to
-// reconnect in case of a heartbeat failure or disconnect.
+// reconnect in case of a heartbeat failure or disconnect, following conn.config.Reconnect.
 func (conn *tunnelConn) serve(ctx context.Context) (err error) {
 	defer close(conn.ack)
-	defer close(conn.inbound)
+	defer func() {
+		// Wait for every in-flight handleTunnelReq delivery to finish before closing inbound, so
+		// a goroutine parked on `conn.inbound <- payload` can never race this close and panic.
+		conn.reqWg.Wait()
+		close(conn.inbound)
+	}()
+	defer close(conn.serveDone)
+
+	policy := conn.config.Reconnect
+	backoff := policy.InitialBackoff
+	attempt := 0
 
 	for {
+		connectedAt := time.Now()
 		err = conn.process(ctx)
 		log(conn, "conn", "Server terminated with error: %v", err)
 
+		// Don't attempt to reconnect while a graceful shutdown is underway; Shutdown is the one
+		// driving the disconnect from here on.
+		select {
+		case <-conn.shutdownC:
+			return
+		default:
+		}
+
 		// Check if we can try again.
-		if err == errDisconnected || err == errHeartbeatFailed {
-			log(conn, "conn", "Attempting reconnect")
+		if err != errDisconnected && err != errHeartbeatFailed {
+			return
+		}
+
+		// A connection that stayed up for the stability window resets the attempt counter and
+		// backoff, so one flaky reconnect doesn't leave a stale, inflated backoff behind.
+		if time.Since(connectedAt) >= policy.StabilityWindow {
+			attempt = 0
+			backoff = policy.InitialBackoff
+		}
+
+		reconnErr := errReconnectAttemptsExhausted
+
+		atomic.StoreInt32(&conn.reconnecting, 1)
+
+		for policy.MaxAttempts <= 0 || attempt < policy.MaxAttempts {
+			attempt++
+			log(conn, "conn", "Attempting reconnect (attempt %d)", attempt)
 
 			reconnCtx, cancelReconn := context.WithTimeout(ctx, conn.config.ResponseTimeout)
-			reconnErr := conn.requestConn(reconnCtx)
+			reconnErr = conn.requestConn(reconnCtx)
 			cancelReconn()
 
+			if policy.OnReconnect != nil {
+				policy.OnReconnect(attempt, reconnErr)
+			}
+
 			if reconnErr == nil {
-				log(conn, "conn", "Reconnect succeeded")
-				continue
+				log(conn, "conn", "Reconnect succeeded after %d attempt(s)", attempt)
+				break
 			}
 
-			log(conn, "conn", "Reconnect failed: %v", reconnErr)
+			log(conn, "conn", "Reconnect attempt %d failed: %v", attempt, reconnErr)
+
+			if policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts {
+				break
+			}
+
+			select {
+			case <-ctx.Done():
+				atomic.StoreInt32(&conn.reconnecting, 0)
+				return ctx.Err()
+			case <-conn.shutdownC:
+				atomic.StoreInt32(&conn.reconnecting, 0)
+				return err
+			case <-time.After(backoffDelay(backoff, policy.Jitter)):
+			}
+
+			backoff = time.Duration(float64(backoff) * policy.BackoffFactor)
+			if backoff > policy.MaxBackoff {
+				backoff = policy.MaxBackoff
+			}
 		}
 
-		return
+		atomic.StoreInt32(&conn.reconnecting, 0)
+
+		if reconnErr != nil {
+			log(conn, "conn", "Giving up reconnecting after %d attempt(s)", attempt)
+			return
+		}
 	}
 }
 
@@ -519,10 +739,12 @@ func NewTunnel(gatewayAddr string, config TunnelConfig) (*Tunnel, error) {
 	// Initialize the Client structure.
 	client := &Tunnel{
 		tunnelConn: tunnelConn{
-			sock:    sock,
-			config:  checkTunnelConfig(config),
-			ack:     make(chan *proto.TunnelRes),
-			inbound: make(chan cemi.Message),
+			sock:      sock,
+			config:    checkTunnelConfig(config),
+			ack:       make(chan *proto.TunnelRes),
+			inbound:   make(chan cemi.Message),
+			shutdownC: make(chan struct{}),
+			serveDone: make(chan struct{}),
 		},
 		ctx:    ctx,
 		cancel: cancel,
@@ -544,29 +766,139 @@ func NewTunnel(gatewayAddr string, config TunnelConfig) (*Tunnel, error) {
 	return client, nil
 }
 
-// Close will terminate the connection.
+// NewBusMonitor establishes a read-only bus-monitor tunnel with a gateway. It behaves like
+// NewTunnel, except that config.Layer is forced to proto.TunnelLayerBusmon, so the gateway sends
+// L_Busmon.ind frames on Inbound instead of participating in the bus as a device. Send and
+// SendContext on the returned Tunnel always fail with ErrBusmonReadOnly.
+//
+// TODO: cemi has no L_Busmon.ind decoder yet, so those frames currently surface on Inbound as
+// *cemi.UnsupportedMessage rather than a typed indication. Readable ETS-style bus traces need
+// that decoder added to the cemi package; this is a known gap, not something this constructor
+// can address on its own.
+func NewBusMonitor(gatewayAddr string, config TunnelConfig) (*Tunnel, error) {
+	config.Layer = proto.TunnelLayerBusmon
+	return NewTunnel(gatewayAddr, config)
+}
+
+// Close will terminate the connection immediately. Any in-flight Send call is aborted and
+// whatever is still buffered on Inbound is dropped. Use Shutdown if you need an orderly
+// disconnect instead.
 func (client *Tunnel) Close() {
 	client.requestDisc()
 	client.cancel()
 	client.sock.Close()
 }
 
+// beginShutdown marks the tunnel as shutting down, preventing any further Send call from being
+// tracked in sendWg. It is safe to call more than once.
+func (client *Tunnel) beginShutdown() {
+	client.shutdownMu.Lock()
+	defer client.shutdownMu.Unlock()
+
+	select {
+	case <-client.shutdownC:
+	default:
+		close(client.shutdownC)
+	}
+}
+
+// Shutdown performs an orderly shutdown of the tunnel. It immediately stops accepting new Send
+// calls, which will return ErrShuttingDown, then waits for any tunnel request that is already in
+// flight to be acknowledged, or for ctx to expire. It then asks the gateway to disconnect and
+// gives the serve loop a bounded grace period, taken from TunnelConfig.ShutdownGracePeriod, to
+// drain whatever is still buffered on Inbound to the consumer and observe the disconnect
+// response before the connection is closed. If ctx is canceled before the handshake completes,
+// Shutdown falls back to an abrupt Close.
+func (client *Tunnel) Shutdown(ctx context.Context) error {
+	client.beginShutdown()
+
+	// Wait for in-flight Send calls to finish, or the caller to give up on us.
+	sendsDone := make(chan struct{})
+	go func() {
+		client.sendWg.Wait()
+		close(sendsDone)
+	}()
+
+	select {
+	case <-sendsDone:
+	case <-ctx.Done():
+		client.Close()
+		return ctx.Err()
+	}
+
+	// Ask the gateway to disconnect. The serve loop will keep draining Inbound and will return
+	// on its own once handleDiscRes sees the response.
+	if err := client.requestDisc(); err != nil {
+		client.Close()
+		return err
+	}
+
+	graceCtx, cancelGrace := context.WithTimeout(ctx, client.config.ShutdownGracePeriod)
+	defer cancelGrace()
+
+	select {
+	case <-client.serveDone:
+	case <-graceCtx.Done():
+	}
+
+	client.cancel()
+	client.sock.Close()
+
+	return nil
+}
+
 // Inbound retrieves the channel which transmits incoming data.
 func (client *Tunnel) Inbound() <-chan cemi.Message {
 	return client.inbound
 }
 
-// Send relays a tunnel request to the gateway with the given contents.
-func (client *Tunnel) Send(data cemi.Message) error {
-	// Prepare a context, so that we won't wait forever for a tunnel response.
-	ctx, cancel := context.WithTimeout(client.ctx, client.config.ResponseTimeout)
-	defer cancel()
+// InboundContext awaits a single incoming message, or returns ctx.Err() if ctx is done first.
+// It also reports an error if the tunnel's inbound channel has been closed, e.g. because the
+// tunnel has disconnected.
+func (client *Tunnel) InboundContext(ctx context.Context) (cemi.Message, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+
+	case msg, open := <-client.inbound:
+		if !open {
+			return nil, errInboundClosed
+		}
 
-	// Send the tunnel reqest.
-	err := client.requestTunnel(ctx, data)
-	if err != nil {
-		return err
+		return msg, nil
 	}
+}
 
-	return nil
-}
\ No newline at end of file
+// SendContext relays a tunnel request to the gateway with the given contents. It is combined
+// with the TunnelConfig.ResponseTimeout, so the effective deadline is whichever of ctx or the
+// response timeout elapses first. Cancelling ctx lets a caller give up on an individual Send
+// without tearing down the whole tunnel.
+func (client *Tunnel) SendContext(ctx context.Context, data cemi.Message) error {
+	if client.config.Layer == proto.TunnelLayerBusmon {
+		return ErrBusmonReadOnly
+	}
+
+	client.shutdownMu.RLock()
+	select {
+	case <-client.shutdownC:
+		client.shutdownMu.RUnlock()
+		return ErrShuttingDown
+	default:
+	}
+
+	client.sendWg.Add(1)
+	client.shutdownMu.RUnlock()
+	defer client.sendWg.Done()
+
+	// Combine the caller's context with the response timeout, so that we won't wait forever for
+	// a tunnel response.
+	ctx, cancel := context.WithTimeout(ctx, client.config.ResponseTimeout)
+	defer cancel()
+
+	return client.requestTunnel(ctx, data)
+}
+
+// Send relays a tunnel request to the gateway with the given contents.
+func (client *Tunnel) Send(data cemi.Message) error {
+	return client.SendContext(client.ctx, data)
+}